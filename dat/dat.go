@@ -0,0 +1,101 @@
+// Package dat parses Logiqx-style DAT/XML files (as published by No-Intro
+// and Redump) and resolves a ROM's hashes to its canonical name.
+package dat
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// Entry is a single <rom> record from the DAT, scoped to the <game> it was
+// declared under.
+type Entry struct {
+	Name string // Canonical filename, taken from the <game name="..."> attribute
+	CRC  string
+	MD5  string
+	SHA1 string
+}
+
+// Dat is a parsed DAT file, indexed by hash for fast lookup. Multiple
+// entries can share a hash (e.g. region-renamed reuploads of the same dump),
+// so lookups return every candidate and let the caller disambiguate.
+type Dat struct {
+	byCRC  map[string][]Entry
+	bySHA1 map[string][]Entry
+}
+
+type logiqxFile struct {
+	Games []logiqxGame `xml:"game"`
+}
+
+type logiqxGame struct {
+	Name string      `xml:"name,attr"`
+	Roms []logiqxRom `xml:"rom"`
+}
+
+type logiqxRom struct {
+	CRC  string `xml:"crc,attr"`
+	MD5  string `xml:"md5,attr"`
+	SHA1 string `xml:"sha1,attr"`
+}
+
+// Parse reads a Logiqx-style DAT/XML document, e.g.:
+//
+//	<datafile><game name="..."><rom crc="..." md5="..." sha1="..." size="..."/></game></datafile>
+func Parse(r io.Reader) (*Dat, error) {
+	var file logiqxFile
+	if err := xml.NewDecoder(r).Decode(&file); err != nil {
+		return nil, err
+	}
+
+	d := &Dat{
+		byCRC:  map[string][]Entry{},
+		bySHA1: map[string][]Entry{},
+	}
+
+	for _, game := range file.Games {
+		for _, rom := range game.Roms {
+			entry := Entry{
+				Name: game.Name,
+				CRC:  strings.ToLower(rom.CRC),
+				MD5:  strings.ToLower(rom.MD5),
+				SHA1: strings.ToLower(rom.SHA1),
+			}
+
+			if entry.CRC != "" {
+				d.byCRC[entry.CRC] = append(d.byCRC[entry.CRC], entry)
+			}
+			if entry.SHA1 != "" {
+				d.bySHA1[entry.SHA1] = append(d.bySHA1[entry.SHA1], entry)
+			}
+		}
+	}
+
+	return d, nil
+}
+
+// Candidates returns every entry matching crc, falling back to sha1 when crc
+// isn't found (e.g. the DAT only published SHA1s). When several entries share
+// crc and some of them also match sha1, only those are returned, so a CRC
+// collision is disambiguated against the fuller hash.
+func (d *Dat) Candidates(crc, sha1 string) ([]Entry, bool) {
+	if entries, ok := d.byCRC[strings.ToLower(crc)]; ok {
+		if len(entries) > 1 && sha1 != "" {
+			var narrowed []Entry
+			for _, entry := range entries {
+				if entry.SHA1 == strings.ToLower(sha1) {
+					narrowed = append(narrowed, entry)
+				}
+			}
+			if len(narrowed) > 0 {
+				return narrowed, true
+			}
+		}
+		return entries, true
+	}
+	if entries, ok := d.bySHA1[strings.ToLower(sha1)]; ok {
+		return entries, true
+	}
+	return nil, false
+}
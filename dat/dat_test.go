@@ -0,0 +1,88 @@
+package dat
+
+import (
+	"strings"
+	"testing"
+)
+
+const sampleDat = `<?xml version="1.0"?>
+<datafile>
+	<game name="Super Game (USA)">
+		<rom name="Super Game (USA).bin" size="1" crc="aabbccdd" md5="11223344556677889900112233445566" sha1="1122334455667788990011223344556677889900"/>
+	</game>
+	<game name="Super Game (Europe)">
+		<rom name="Super Game (Europe).bin" size="1" crc="aabbccdd" md5="00112233445566778899001122334455" sha1="0011223344556677889900112233445566778899"/>
+	</game>
+	<game name="Other Game (USA)">
+		<rom name="Other Game (USA).bin" size="1" crc="deadbeef" sha1="deadbeefdeadbeefdeadbeefdeadbeefdeadbeef"/>
+	</game>
+</datafile>
+`
+
+func TestParse(t *testing.T) {
+	d, err := Parse(strings.NewReader(sampleDat))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	candidates, ok := d.Candidates("AABBCCDD", "")
+	if !ok {
+		t.Fatalf("Candidates(AABBCCDD): not found")
+	}
+	if len(candidates) != 2 {
+		t.Fatalf("Candidates(AABBCCDD): got %d entries, want 2", len(candidates))
+	}
+
+	candidates, ok = d.Candidates("", "DEADBEEFDEADBEEFDEADBEEFDEADBEEFDEADBEEF")
+	if !ok {
+		t.Fatalf("Candidates(sha1 fallback): not found")
+	}
+	if len(candidates) != 1 || candidates[0].Name != "Other Game (USA)" {
+		t.Fatalf("Candidates(sha1 fallback): got %+v", candidates)
+	}
+
+	if _, ok := d.Candidates("00000000", "0000000000000000000000000000000000000000"); ok {
+		t.Fatalf("Candidates(unknown): want not found")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	candidates := []Entry{
+		{Name: "Super Game (USA)"},
+		{Name: "Super Game (Europe)"},
+	}
+
+	tests := []struct {
+		currentName string
+		want        string
+	}{
+		{"Super Game (USA)", "Super Game (USA)"},
+		{"Super Game (Europe)", "Super Game (Europe)"},
+		{"Super Game (USA) [hack]", "Super Game (USA)"},
+	}
+
+	for _, tt := range tests {
+		got := Resolve(candidates, tt.currentName)
+		if got.Name != tt.want {
+			t.Errorf("Resolve(%q) = %q, want %q", tt.currentName, got.Name, tt.want)
+		}
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "abc", 0},
+		{"abc", "", 3},
+		{"kitten", "sitting", 3},
+	}
+
+	for _, tt := range tests {
+		if got := levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
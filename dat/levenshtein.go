@@ -0,0 +1,56 @@
+package dat
+
+// Resolve picks the best Entry among candidates for a ROM currently named
+// currentName, preferring the entry whose canonical Name is closest to it by
+// Levenshtein distance. Used when multiple DAT entries share a hash (e.g.
+// region-renamed reuploads of the same dump).
+func Resolve(candidates []Entry, currentName string) Entry {
+	best := candidates[0]
+	bestDistance := levenshtein(currentName, best.Name)
+
+	for _, candidate := range candidates[1:] {
+		if d := levenshtein(currentName, candidate.Name); d < bestDistance {
+			best = candidate
+			bestDistance = d
+		}
+	}
+
+	return best
+}
+
+// levenshtein computes the edit distance between a and b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(curr[j-1]+1, prev[j]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	m := a
+	if b < m {
+		m = b
+	}
+	if c < m {
+		m = c
+	}
+	return m
+}
@@ -0,0 +1,131 @@
+// Package dedup detects duplicate ROM content across the upstreams romfu is
+// about to union-mount, so only one copy of each unique ROM contributes to
+// the final filesystem.
+package dedup
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/thatpix3l/romfu/hashutil"
+)
+
+// Deduper tracks which content hashes have already been declared, so
+// callers can skip upstreams that would contribute a duplicate.
+type Deduper interface {
+	// Seen reports whether hash has already been declared.
+	Seen(hash string) bool
+	// Declare records hash as having been contributed by an upstream.
+	Declare(hash string)
+}
+
+// memDeduper is an in-memory Deduper backed by a map. It's the default for
+// libraries small enough to fit their hash set in memory; huge libraries can
+// provide their own on-disk-backed Deduper (e.g. bolt/badger) instead.
+type memDeduper struct {
+	seen map[string]bool
+}
+
+// NewMemDeduper returns an in-memory Deduper.
+func NewMemDeduper() Deduper {
+	return &memDeduper{seen: map[string]bool{}}
+}
+
+func (d *memDeduper) Seen(hash string) bool {
+	return d.seen[hash]
+}
+
+func (d *memDeduper) Declare(hash string) {
+	d.seen[hash] = true
+}
+
+// Result reports what Prune did to a single rom, identified by its
+// highest-priority layer directory.
+type Result struct {
+	Dir        string
+	Kept       bool
+	BytesSaved int64
+}
+
+// Prune hashes every file across each rom's layers (dirs[i] is one rom's
+// ordered list of layer directories, e.g. platform.Rom.Layers()) and, using
+// dedupe, keeps only the first rom to contribute any given hash. It returns
+// one Result per input rom, in order, so callers can report what was
+// skipped and how many bytes were saved.
+func Prune(dirs [][]string, dedupe Deduper) ([]Result, error) {
+	results := make([]Result, 0, len(dirs))
+
+	for _, layers := range dirs {
+		hash, size, empty, err := hashLayers(layers)
+		if err != nil {
+			return nil, err
+		}
+
+		dir := layers[0]
+
+		// A rom with no regular files in any layer has nothing to compare by
+		// content, so it can never be recognized as a duplicate: otherwise
+		// every such rom would share the same empty hash and all but the
+		// first would be pruned despite having nothing in common.
+		if empty {
+			results = append(results, Result{Dir: dir, Kept: true})
+			continue
+		}
+
+		if dedupe.Seen(hash) {
+			results = append(results, Result{Dir: dir, Kept: false, BytesSaved: size})
+			continue
+		}
+
+		dedupe.Declare(hash)
+		results = append(results, Result{Dir: dir, Kept: true})
+	}
+
+	return results, nil
+}
+
+// hashLayers returns a combined SHA1 over every regular file directly under
+// each of layers (files within a layer sorted by name, layers taken in the
+// order given, so the result is stable regardless of directory iteration
+// order), the total size of those files, and whether no regular files were
+// found at all.
+func hashLayers(layers []string) (hash string, total int64, empty bool, err error) {
+	var combined strings.Builder
+	var fileCount int
+
+	for _, dir := range layers {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return "", 0, false, err
+		}
+
+		var names []string
+		sizes := map[string]int64{}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			names = append(names, entry.Name())
+			if info, err := entry.Info(); err == nil {
+				sizes[entry.Name()] = info.Size()
+			}
+		}
+
+		// Files within a layer are typically just the one NSP/ISO/CIA, so
+		// hash each individually and combine. Every file counts, so two
+		// directories with the same ROM file but different incidental
+		// siblings (e.g. a stray .txt) are not recognized as duplicates.
+		for _, name := range names {
+			hashes, err := hashutil.HashesForFile(filepath.Join(dir, name))
+			if err != nil {
+				return "", 0, false, err
+			}
+			combined.WriteString(hashes.SHA1)
+			total += sizes[name]
+			fileCount++
+		}
+	}
+
+	return combined.String(), total, fileCount == 0, nil
+}
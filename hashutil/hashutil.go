@@ -0,0 +1,48 @@
+// Package hashutil computes the set of hashes romfu uses to identify ROM
+// files, shared by the rename and dedup commands.
+package hashutil
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// FileHashes holds the hex-encoded checksums of a single file. Only CRC32
+// and SHA1 are computed: those are the only hashes dat.Dat indexes by, so a
+// third (MD5) would just be wasted work on every file in the library.
+type FileHashes struct {
+	CRC32 string
+	SHA1  string
+}
+
+// HashesForFile streams filePath's contents through CRC32/SHA1 simultaneously,
+// avoiding loading the whole file into memory.
+func HashesForFile(filePath string) (FileHashes, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return FileHashes{}, err
+	}
+	defer f.Close()
+
+	return HashesForReader(f)
+}
+
+// HashesForReader is like HashesForFile but operates on an already-open
+// reader, so callers (e.g. the zip-aware ROM walker) can hash an inner file
+// without extracting it to disk first.
+func HashesForReader(r io.Reader) (FileHashes, error) {
+	crcHash := crc32.NewIEEE()
+	sha1Hash := sha1.New()
+
+	if _, err := io.Copy(io.MultiWriter(crcHash, sha1Hash), r); err != nil {
+		return FileHashes{}, err
+	}
+
+	return FileHashes{
+		CRC32: hex.EncodeToString(crcHash.Sum(nil)),
+		SHA1:  hex.EncodeToString(sha1Hash.Sum(nil)),
+	}, nil
+}
@@ -1,17 +1,27 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
 	"fmt"
-	"io/ioutil"
 	"log"
-	"math/rand"
+	"math/big"
 	"os"
-	"os/exec"
+	"os/signal"
 	"path"
 	"strings"
+	"syscall"
 
 	"github.com/alecthomas/kong"
 	"github.com/fatih/color"
+	"github.com/rclone/rclone/fs"
+
+	"github.com/thatpix3l/romfu/dat"
+	"github.com/thatpix3l/romfu/dedup"
+	"github.com/thatpix3l/romfu/platform"
+	"github.com/thatpix3l/romfu/rcfs"
+	"github.com/thatpix3l/romfu/remote"
+	"github.com/thatpix3l/romfu/rename"
 )
 
 // Format path to format for rclone's config
@@ -24,130 +34,224 @@ func fmtRclone(remote string, path string, options ...string) string {
 	return formattedStr
 }
 
-// ROM path
-type Rom struct {
-	DirPath    string // Path to the ROM's top-level directory e.g. /path/to/game
-	SubdirName string // Chosen subdirectory in the root directory used for the ROM e.g. "merged" or "base"
+// backendCmd is the set of flags shared by every subcommand that builds
+// romfu's composed filesystem (fs, serve), regardless of how that
+// filesystem is ultimately exposed.
+type backendCmd struct {
+	EnableWrite bool   `short:"w" help:"enable writing to the composed filesystem"`
+	InputDir    string `short:"i" required:"" help:"path to directory containing subdirectories of games"`
+	Dedup       bool   `help:"skip upstreams whose ROM content duplicates one already selected"`
+
+	Encrypt         bool   `help:"encrypt the composed filesystem with rclone's crypt backend"`
+	EncryptPassword string `help:"password for --encrypt; a random one is generated and printed if omitted"`
+	Compress        bool   `help:"transparently compress the composed filesystem with rclone's compress backend"`
+	ChunkSize       string `help:"split files larger than this with rclone's chunker backend, e.g. 4G"`
+	HashCache       bool   `help:"cache upstream hashes with rclone's hasher backend"`
 }
 
-// Merged root and subdirectory e.g. /path/to/game/{merged,base}
-func (r Rom) Parent() string {
-	return path.Join(r.DirPath, r.SubdirName)
+// fsCmd is the set of flags shared by every platform's "fs" subcommand.
+type fsCmd struct {
+	backendCmd
+
+	OutputDir string `short:"o" required:"" help:"path to directory for mounting the flat filesystem"`
+}
+
+// renameCmd is the set of flags shared by every platform's "rename"
+// subcommand.
+type renameCmd struct {
+	InputDir     string `short:"i" required:"" help:"path to directory containing ROMs to rename"`
+	DatFile      string `short:"d" required:"" help:"path to a Logiqx-style DAT/XML file (No-Intro, Redump, or your own)"`
+	DryRun       bool   `help:"print \"old -> new\" instead of touching any files"`
+	KeepOriginal bool   `help:"hardlink to the canonical name instead of renaming"`
+	Workers      int    `default:"4" help:"number of parallel hashing workers"`
 }
 
-var invalidGameDirNames = []string{"rw", "titles"} // Invalid names of ROM directories in root of provided game library
-var validSubdirNames = []string{"merged", "base"}  // Name of usable subdirectories for each switch game
+// serveCmd is the set of flags shared by every platform's "serve"
+// subcommand. It builds the same composed filesystem as "fs", but exposes
+// it over a network protocol instead of FUSE-mounting it, for hosts where
+// mounting isn't practical.
+type serveCmd struct {
+	backendCmd
 
-type Command interface {
-	Action()
-	Subcommands() []Command
+	Protocol string `enum:"ftp,http,webdav,sftp" default:"http" help:"protocol to serve the filesystem over"`
+	Addr     string `default:":8080" help:"address to listen on"`
 }
 
 var CLI struct {
 	Switch *struct {
-		FS *struct {
-			EnableWrite bool   `short:"w" help:"enable writing to output directory"`
-			InputDir    string `short:"i" required:"" help:"path to directory containing subdirectories of switch games"`
-			OutputDir   string `short:"o" required:"" help:"path to directory for mounting the flat filesystem"`
-		} `cmd`
+		FS     *fsCmd     `cmd`
+		Rename *renameCmd `cmd`
+		Serve  *serveCmd  `cmd`
 	} `cmd`
+	ThreeDS *struct {
+		FS     *fsCmd     `cmd`
+		Rename *renameCmd `cmd`
+		Serve  *serveCmd  `cmd`
+	} `cmd name:"3ds"`
+	PS2 *struct {
+		FS     *fsCmd     `cmd`
+		Rename *renameCmd `cmd`
+		Serve  *serveCmd  `cmd`
+	} `cmd name:"ps2"`
+	GameCube *struct {
+		FS     *fsCmd     `cmd`
+		Rename *renameCmd `cmd`
+		Serve  *serveCmd  `cmd`
+	} `cmd name:"gamecube"`
 }
 
 var letterRunes = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
 
-// Create a random string of length "n"
+// RandString returns a cryptographically random string of length n, suitable
+// for use as a generated secret (e.g. the --encrypt password).
 func RandString(n int) string {
 	b := make([]rune, n)
 	for i := range b {
-		b[i] = letterRunes[rand.Intn(len(letterRunes))]
+		idx, err := rand.Int(rand.Reader, big.NewInt(int64(len(letterRunes))))
+		if err != nil {
+			log.Fatal(err)
+		}
+		b[i] = letterRunes[idx.Int64()]
 	}
 	return string(b)
 }
 
-type Action func()
-
 func main() {
 
 	if err := kong.Parse(&CLI).Error; err != nil {
 		log.Fatal(err)
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigCh
+		cancel()
+	}()
+
 	switch {
-	case CLI.Switch != nil:
-		switch {
-		case CLI.Switch.FS != nil:
-			createFS()
-		}
+	case CLI.Switch != nil && CLI.Switch.FS != nil:
+		createFS(ctx, platformOrFatal("switch"), CLI.Switch.FS)
+	case CLI.Switch != nil && CLI.Switch.Rename != nil:
+		renameROMs(CLI.Switch.Rename)
+	case CLI.Switch != nil && CLI.Switch.Serve != nil:
+		serveFS(ctx, platformOrFatal("switch"), CLI.Switch.Serve)
+	case CLI.ThreeDS != nil && CLI.ThreeDS.FS != nil:
+		createFS(ctx, platformOrFatal("3ds"), CLI.ThreeDS.FS)
+	case CLI.ThreeDS != nil && CLI.ThreeDS.Rename != nil:
+		renameROMs(CLI.ThreeDS.Rename)
+	case CLI.ThreeDS != nil && CLI.ThreeDS.Serve != nil:
+		serveFS(ctx, platformOrFatal("3ds"), CLI.ThreeDS.Serve)
+	case CLI.PS2 != nil && CLI.PS2.FS != nil:
+		createFS(ctx, platformOrFatal("ps2"), CLI.PS2.FS)
+	case CLI.PS2 != nil && CLI.PS2.Rename != nil:
+		renameROMs(CLI.PS2.Rename)
+	case CLI.PS2 != nil && CLI.PS2.Serve != nil:
+		serveFS(ctx, platformOrFatal("ps2"), CLI.PS2.Serve)
+	case CLI.GameCube != nil && CLI.GameCube.FS != nil:
+		createFS(ctx, platformOrFatal("gamecube"), CLI.GameCube.FS)
+	case CLI.GameCube != nil && CLI.GameCube.Rename != nil:
+		renameROMs(CLI.GameCube.Rename)
+	case CLI.GameCube != nil && CLI.GameCube.Serve != nil:
+		serveFS(ctx, platformOrFatal("gamecube"), CLI.GameCube.Serve)
 	}
 
 }
 
-func createFS() {
-
-	gamesSrcPath := CLI.Switch.FS.InputDir
-	gamesDestPath := CLI.Switch.FS.OutputDir
-
-	// Read in given directory containing switch games
-	files, err := ioutil.ReadDir(gamesSrcPath)
+// renameROMs loads the DAT file named by cmd.DatFile and renames every
+// matching ROM under cmd.InputDir to its canonical name.
+func renameROMs(cmd *renameCmd) {
+	f, err := os.Open(cmd.DatFile)
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer f.Close()
 
-	// Path to each game directory's "merged" or "base" directory.
-	// Example: /absolute/path/to/game/dir/containing/one/nsp
-	// This directory path would contain only one NSP, the game itself.
-	// Whether merged or not depends if the "merged" dir exists.
-	roms := []Rom{}
-
-	var detectedGames string
+	parsedDat, err := dat.Parse(f)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	// For any file/folder in the provided game library...
-	for _, file := range files {
+	results, err := rename.Run(rename.Options{
+		Dat:          parsedDat,
+		RootDir:      cmd.InputDir,
+		DryRun:       cmd.DryRun,
+		KeepOriginal: cmd.KeepOriginal,
+		Workers:      cmd.Workers,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
 
-		gameDirName := file.Name()
+	fmt.Printf("renamed %d ROM(s)\n", len(results))
+}
 
-		// If not a directory, skip
-		if !file.IsDir() {
-			continue
-		}
+// platformOrFatal looks up a registered platform by name, exiting if it
+// somehow isn't registered (a programming error, not a user one).
+func platformOrFatal(name string) platform.Platform {
+	p, ok := platform.Lookup(name)
+	if !ok {
+		log.Fatalf("no platform registered under %q", name)
+	}
+	return p
+}
 
-		// If name of directory starts with a period (basically, if hidden), skip
-		if strings.HasPrefix(gameDirName, ".") {
-			continue
-		}
+// dedupROMs prunes roms whose ROM content (hashed across all of its Layers())
+// duplicates a ROM already kept, reporting the bytes saved.
+func dedupROMs(roms []platform.Rom) []platform.Rom {
+	layers := make([][]string, len(roms))
+	for i, rom := range roms {
+		layers[i] = rom.Layers()
+	}
 
-		// If name of directory is one of the blacklisted names, skip
-		isBlacklisted := func() bool {
-			for _, invalidDirName := range invalidGameDirNames {
-				if gameDirName == invalidDirName {
-					return true
-				}
-			}
-			return false
-		}
+	results, err := dedup.Prune(layers, dedup.NewMemDeduper())
+	if err != nil {
+		log.Fatal(err)
+	}
 
-		if isBlacklisted() {
+	kept := make([]platform.Rom, 0, len(roms))
+	var bytesSaved int64
+	for i, result := range results {
+		if result.Kept {
+			kept = append(kept, roms[i])
 			continue
 		}
+		bytesSaved += result.BytesSaved
+	}
 
-		dirPath := path.Join(gamesSrcPath, gameDirName)
+	fmt.Printf("deduped %s across %d duplicate ROM(s)\n", color.YellowString(formatBytes(bytesSaved)), len(roms)-len(kept))
 
-		// For each valid ROM subdirectory name...
-		for _, subdirName := range validSubdirNames {
+	return kept
+}
 
-			// Create a path, joining the path to the game's directory with the name of a subdir
-			// Example: /path/to/game/root + romDirName = /path/to/game/root/romDirName
-			romDirPath := path.Join(dirPath, subdirName)
+// formatBytes renders n bytes as a human-readable size, e.g. "1.5 GiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
 
-			// If it exists, only use the one rom directory.
-			if stat, err := os.Stat(romDirPath); err == nil && stat != nil && stat.IsDir() {
-				detectedGames += fmt.Sprintf("\"%s\" -> \"%s\"\n", color.BlueString(gameDirName), subdirName)
-				roms = append(roms, Rom{DirPath: dirPath, SubdirName: subdirName})
-				break
-			}
+// buildComposedFS detects plat's ROMs under cmd.InputDir, applies dedup if
+// requested, and builds the in-process rclone fs.Fs for the resulting union
+// (plus any backend chain layered over it). This is the shared core of both
+// "fs" (FUSE mount) and "serve" (network protocol).
+func buildComposedFS(ctx context.Context, plat platform.Platform, cmd *backendCmd) fs.Fs {
 
-		}
+	gamesSrcPath := cmd.InputDir
 
+	roms, err := plat.DetectROMs(ctx, gamesSrcPath)
+	if err != nil {
+		log.Fatal(err)
 	}
 
 	// If no ROM directories were even detected, quit early
@@ -155,59 +259,82 @@ func createFS() {
 		log.Fatal("no valid game folders found in given directory")
 	}
 
+	if cmd.Dedup {
+		roms = dedupROMs(roms)
+	}
+
+	var detectedGames string
+	for _, rom := range roms {
+		detectedGames += fmt.Sprintf("\"%s\" -> %q\n", color.BlueString(rom.DirPath), rom.SubdirNames)
+	}
+
 	fmt.Println("Games:")
 	fmt.Println(detectedGames)
 
 	remoteLocal := "ROMFULOCAL"
 	remoteUnion := "ROMFUUNION"
 
-	rcloneConfig := map[string]map[string]string{
-		remoteLocal: {
-			"TYPE": "local",
-		},
-		remoteUnion: {
-			"TYPE":      "union",
-			"UPSTREAMS": "",
-		},
-	}
+	var upstreamList []string
 
 	// If the user wants to enable writing in the final directory, create a separate "rw" directory for all written content
-	if CLI.Switch.FS.EnableWrite {
+	if cmd.EnableWrite {
 		rwDirPath := path.Join(gamesSrcPath, "rw")
 		os.MkdirAll(rwDirPath, 0755)
-		rcloneConfig[remoteUnion]["UPSTREAMS"] += fmtRclone(remoteLocal, rwDirPath) + " "
+		upstreamList = append(upstreamList, fmtRclone(remoteLocal, rwDirPath))
 	}
 
-	// If we have more than one ROM, implement a separator
-	var separator string
-	if len(roms) > 1 {
-		separator = " "
+	// For each detected ROM, add every present layer as an upstream, in
+	// priority order, so union resolves same-named files to the
+	// highest-priority layer (e.g. dlc over update over base).
+	for _, rom := range roms {
+		for _, layer := range rom.Layers() {
+			upstreamList = append(upstreamList, fmtRclone(remoteLocal, layer, "ro"))
+		}
 	}
 
-	// For each detected ROM, add it to the list of upstreams as read-only
-	for _, rom := range roms {
-		rcloneConfig[remoteUnion]["UPSTREAMS"] += fmtRclone(remoteLocal, rom.Parent(), "ro") + separator
+	upstreams := strings.Join(upstreamList, " ")
+
+	localSpec := remote.Spec{Name: remoteLocal, Options: map[string]string{"type": "local"}}
+	unionSpec := remote.Spec{Name: remoteUnion, Options: map[string]string{"type": "union", "upstreams": upstreams}}
+
+	if cmd.Encrypt && cmd.EncryptPassword == "" {
+		cmd.EncryptPassword = RandString(32)
+		fmt.Printf("generated encryption password: %s\n", color.YellowString(cmd.EncryptPassword))
 	}
 
-	// Apply rcloneConfig as environment variables
-	for remoteName, remoteConfig := range rcloneConfig {
-		for remoteOptionName, remoteOptionValue := range remoteConfig {
-			envKey := "RCLONE_CONFIG_" + remoteName + "_" + remoteOptionName
-			if err := os.Setenv(envKey, remoteOptionValue); err != nil {
-				log.Fatal(err)
-			}
-		}
+	chainSpecs, topRemote, err := remote.Chain(unionSpec, remote.Options{
+		ChunkSize: cmd.ChunkSize,
+		Compress:  cmd.Compress,
+		Encrypt:   cmd.Encrypt,
+		Password:  cmd.EncryptPassword,
+		HashCache: cmd.HashCache,
+	})
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	// Run rclone command
-	cmd := exec.Command("rclone", "mount", remoteUnion+":", gamesDestPath)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	f, err := rcfs.Build(ctx, append([]remote.Spec{localSpec}, chainSpecs...), topRemote)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	fmt.Println("Rclone command output:")
-	if err := cmd.Run(); err != nil {
+	return f
+}
+
+func createFS(ctx context.Context, plat platform.Platform, fsArgs *fsCmd) {
+	f := buildComposedFS(ctx, plat, &fsArgs.backendCmd)
+
+	fmt.Printf("mounting at %s\n", fsArgs.OutputDir)
+	if err := rcfs.Mount(f, fsArgs.OutputDir, fsArgs.EnableWrite); err != nil {
 		log.Fatal(err)
 	}
+}
 
+func serveFS(ctx context.Context, plat platform.Platform, serveArgs *serveCmd) {
+	f := buildComposedFS(ctx, plat, &serveArgs.backendCmd)
+
+	fmt.Printf("serving %s on %s\n", serveArgs.Protocol, serveArgs.Addr)
+	if err := rcfs.Serve(ctx, f, serveArgs.Protocol, serveArgs.Addr, serveArgs.EnableWrite); err != nil {
+		log.Fatal(err)
+	}
 }
@@ -0,0 +1,179 @@
+package platform
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+)
+
+// classifyFunc inspects a single top-level candidate directory and reports
+// the Rom it represents, if any.
+type classifyFunc func(dirPath string) (Rom, bool)
+
+// workResult is one candidate directory's classification outcome, reported
+// by a worker goroutine back to the single collecting goroutine.
+type workResult struct {
+	rom Rom
+	ok  bool
+}
+
+// walkAndClassify is the shared concurrent discovery strategy: a producer
+// goroutine walks rootDir one level deep with filepath.WalkDir, pushing
+// candidate directory paths onto a buffered channel; runtime.NumCPU()
+// worker goroutines consume them and run classify. It honors ctx
+// cancellation (e.g. from a SIGINT/SIGTERM handler) so partial discovery
+// unwinds cleanly. The calling goroutine is the sole consumer of results,
+// so it alone owns the live scanned/detected progress line, and sorts the
+// detected ROMs by directory path before returning so the result order
+// (and the union mount order it feeds) is stable across runs regardless of
+// worker scheduling.
+func walkAndClassify(ctx context.Context, rootDir string, invalidDirNames []string, classify classifyFunc) ([]Rom, error) {
+	candidates := make(chan string, runtime.NumCPU())
+
+	var walkErr error
+	go func() {
+		defer close(candidates)
+		walkErr = filepath.WalkDir(rootDir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if p == rootDir || !d.IsDir() {
+				return nil
+			}
+
+			name := d.Name()
+			if strings.HasPrefix(name, ".") || isBlacklisted(name, invalidDirNames) {
+				return filepath.SkipDir
+			}
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case candidates <- p:
+			}
+
+			// Only the top level is a candidate; don't descend into it.
+			return filepath.SkipDir
+		})
+	}()
+
+	workers := runtime.NumCPU()
+	if workers < 1 {
+		workers = 1
+	}
+
+	results := make(chan workResult, workers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for dirPath := range candidates {
+				rom, ok := classify(dirPath)
+				results <- workResult{rom: rom, ok: ok}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	roms := []Rom{}
+	var scanned, detected int
+	for res := range results {
+		scanned++
+		if res.ok {
+			detected++
+			roms = append(roms, res.rom)
+		}
+		fmt.Printf("\r%s %d scanned, %d detected", color.CyanString("scanning..."), scanned, detected)
+	}
+	fmt.Println()
+
+	sort.Slice(roms, func(i, j int) bool { return roms[i].DirPath < roms[j].DirPath })
+
+	if walkErr != nil {
+		return roms, walkErr
+	}
+
+	return roms, ctx.Err()
+}
+
+// detectBySubdir is the shared one-level detection strategy: for each
+// directory directly under rootDir, collect every one of validSubdirNames
+// that exists as a directory inside it, keeping validSubdirNames' order
+// (most complete first) so the caller can union them with the right
+// priority. This is the layout used by platforms whose ROM content lives in
+// well-known subdirectories (e.g. Switch's "merged"/"base", or 3DS's
+// "dlc"/"update"/"base").
+func detectBySubdir(ctx context.Context, rootDir string, invalidDirNames []string, validSubdirNames []string) ([]Rom, error) {
+	return walkAndClassify(ctx, rootDir, invalidDirNames, func(dirPath string) (Rom, bool) {
+		var present []string
+		for _, subdirName := range validSubdirNames {
+			romDirPath := path.Join(dirPath, subdirName)
+
+			if stat, err := os.Stat(romDirPath); err == nil && stat.IsDir() {
+				present = append(present, subdirName)
+			}
+		}
+		if len(present) == 0 {
+			return Rom{}, false
+		}
+		return Rom{DirPath: dirPath, SubdirNames: present}, true
+	})
+}
+
+// detectByRomExt is the shared "disc image directly in the game directory"
+// detection strategy: for each directory directly under rootDir, check
+// whether it directly contains a file ending in one of exts.
+func detectByRomExt(ctx context.Context, rootDir string, invalidDirNames []string, exts []string) ([]Rom, error) {
+	return walkAndClassify(ctx, rootDir, invalidDirNames, func(dirPath string) (Rom, bool) {
+		if hasRomExt(dirPath, exts) {
+			return Rom{DirPath: dirPath}, true
+		}
+		return Rom{}, false
+	})
+}
+
+// hasRomExt reports whether dirPath directly contains at least one file
+// ending in one of exts.
+func hasRomExt(dirPath string, exts []string) bool {
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		return false
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		for _, ext := range exts {
+			if strings.HasSuffix(strings.ToLower(entry.Name()), ext) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func isBlacklisted(dirName string, invalidDirNames []string) bool {
+	for _, invalidDirName := range invalidDirNames {
+		if dirName == invalidDirName {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package platform
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectBySubdir(t *testing.T) {
+	root := t.TempDir()
+
+	mkGame := func(name string, subdirs ...string) {
+		dir := filepath.Join(root, name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		for _, subdir := range subdirs {
+			if err := os.MkdirAll(filepath.Join(dir, subdir), 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	mkGame("zelda", "base")
+	mkGame("mario", "dlc", "base")
+	mkGame("no-subdirs-here")
+	if err := os.MkdirAll(filepath.Join(root, "rw"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	roms, err := detectBySubdir(context.Background(), root, []string{"rw"}, []string{"dlc", "update", "base"})
+	if err != nil {
+		t.Fatalf("detectBySubdir: %v", err)
+	}
+
+	if len(roms) != 2 {
+		t.Fatalf("got %d roms, want 2: %+v", len(roms), roms)
+	}
+
+	// Results must be sorted by DirPath regardless of worker scheduling.
+	if roms[0].DirPath != filepath.Join(root, "mario") || roms[1].DirPath != filepath.Join(root, "zelda") {
+		t.Fatalf("roms not sorted by DirPath: %+v", roms)
+	}
+
+	marioRom := roms[0]
+	if len(marioRom.SubdirNames) != 2 || marioRom.SubdirNames[0] != "dlc" || marioRom.SubdirNames[1] != "base" {
+		t.Fatalf("mario SubdirNames = %v, want [dlc base] (priority order)", marioRom.SubdirNames)
+	}
+
+	zeldaRom := roms[1]
+	if len(zeldaRom.SubdirNames) != 1 || zeldaRom.SubdirNames[0] != "base" {
+		t.Fatalf("zelda SubdirNames = %v, want [base]", zeldaRom.SubdirNames)
+	}
+}
+
+func TestDetectByRomExt(t *testing.T) {
+	root := t.TempDir()
+
+	mkGame := func(name, file string) {
+		dir := filepath.Join(root, name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if file != "" {
+			if err := os.WriteFile(filepath.Join(dir, file), nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	mkGame("game-a", "a.iso")
+	mkGame("game-b", "b.ISO")
+	mkGame("not-a-game", "readme.txt")
+
+	roms, err := detectByRomExt(context.Background(), root, nil, []string{".iso"})
+	if err != nil {
+		t.Fatalf("detectByRomExt: %v", err)
+	}
+
+	if len(roms) != 2 {
+		t.Fatalf("got %d roms, want 2: %+v", len(roms), roms)
+	}
+	if roms[0].DirPath != filepath.Join(root, "game-a") || roms[1].DirPath != filepath.Join(root, "game-b") {
+		t.Fatalf("roms not sorted by DirPath: %+v", roms)
+	}
+}
@@ -0,0 +1,29 @@
+package platform
+
+import "context"
+
+// gamecubeRomExts are the disc-image extensions recognized inside a GameCube
+// game directory: Dolphin's compressed RVZ format and the raw ISO/GCM dumps
+// it was derived from.
+var gamecubeRomExts = []string{".rvz", ".iso", ".gcm"}
+
+// GameCubePlatform detects GameCube ROM directories, where a single disc
+// image sits directly inside the game's directory rather than behind a
+// merged/base subdirectory.
+type GameCubePlatform struct{}
+
+func init() {
+	register(GameCubePlatform{})
+}
+
+func (GameCubePlatform) Name() string { return "gamecube" }
+
+func (GameCubePlatform) InvalidDirNames() []string { return []string{"rw"} }
+
+// ValidSubdirNames is empty: GameCube ROMs live directly in the game
+// directory, there's no merged/base convention to pick between.
+func (GameCubePlatform) ValidSubdirNames() []string { return nil }
+
+func (p GameCubePlatform) DetectROMs(ctx context.Context, rootDir string) ([]Rom, error) {
+	return detectByRomExt(ctx, rootDir, p.InvalidDirNames(), gamecubeRomExts)
+}
@@ -0,0 +1,80 @@
+// Package platform defines the pluggable ROM-detection subsystem. Each
+// supported console implements the Platform interface, encapsulating its own
+// on-disk layout quirks while feeding the same union-mount pipeline.
+package platform
+
+import (
+	"context"
+	"path"
+)
+
+// Rom is a single detected ROM on disk, rooted at DirPath. SubdirNames lists
+// every recognized subdirectory present for this title, in priority order
+// (the content that should win when the same file exists in more than one)
+// — e.g. a 3DS title keeping its dlc, update, and base CIAs unpacked
+// separately. Platforms with no subdirectory convention leave it empty and
+// the ROM content lives directly in DirPath.
+type Rom struct {
+	DirPath     string   // Path to the ROM's top-level directory e.g. /path/to/game
+	SubdirNames []string // Present subdirectories, priority order e.g. ["dlc", "update"]
+}
+
+// Layers returns every directory that should be unioned for this ROM, in
+// priority order (content in an earlier layer wins over content in a
+// later one with the same relative path). For platforms without a
+// subdirectory convention this is just DirPath.
+func (r Rom) Layers() []string {
+	if len(r.SubdirNames) == 0 {
+		return []string{r.DirPath}
+	}
+	layers := make([]string, len(r.SubdirNames))
+	for i, subdirName := range r.SubdirNames {
+		layers[i] = path.Join(r.DirPath, subdirName)
+	}
+	return layers
+}
+
+// Platform encapsulates the ROM-layout conventions of a single console so
+// createFS can stay platform-agnostic.
+type Platform interface {
+	// Name is the platform's CLI-facing identifier, e.g. "switch".
+	Name() string
+
+	// DetectROMs walks rootDir and returns every ROM it can find according
+	// to this platform's layout conventions. It stops early and returns
+	// ctx.Err() if ctx is cancelled mid-walk.
+	DetectROMs(ctx context.Context, rootDir string) ([]Rom, error)
+
+	// InvalidDirNames lists directory names at the root of rootDir that
+	// should never be treated as a ROM directory (e.g. scratch dirs used
+	// by romfu itself).
+	InvalidDirNames() []string
+
+	// ValidSubdirNames lists the subdirectory names, in preference order,
+	// that this platform recognizes as holding ROM content.
+	ValidSubdirNames() []string
+}
+
+// registry holds every platform known to romfu, keyed by Name().
+var registry = map[string]Platform{}
+
+// register adds a platform to the registry. Called from each platform's
+// init().
+func register(p Platform) {
+	registry[p.Name()] = p
+}
+
+// Lookup returns the platform registered under name, and whether it exists.
+func Lookup(name string) (Platform, bool) {
+	p, ok := registry[name]
+	return p, ok
+}
+
+// All returns every registered platform.
+func All() []Platform {
+	platforms := make([]Platform, 0, len(registry))
+	for _, p := range registry {
+		platforms = append(platforms, p)
+	}
+	return platforms
+}
@@ -0,0 +1,27 @@
+package platform
+
+import "context"
+
+// ps2RomExts are the disc-image extensions recognized inside a PS2 game
+// directory.
+var ps2RomExts = []string{".iso", ".bin"}
+
+// PS2Platform detects PlayStation 2 ROM directories, following the common
+// "one ISO per folder" convention used by PCSX2 game libraries.
+type PS2Platform struct{}
+
+func init() {
+	register(PS2Platform{})
+}
+
+func (PS2Platform) Name() string { return "ps2" }
+
+func (PS2Platform) InvalidDirNames() []string { return []string{"rw"} }
+
+func (PS2Platform) ValidSubdirNames() []string { return nil }
+
+func (p PS2Platform) DetectROMs(ctx context.Context, rootDir string) ([]Rom, error) {
+	// PS2 shares GameCube's "disc image directly in the game directory"
+	// layout, just with its own set of recognized extensions.
+	return detectByRomExt(ctx, rootDir, p.InvalidDirNames(), ps2RomExts)
+}
@@ -0,0 +1,21 @@
+package platform
+
+import "context"
+
+// SwitchPlatform detects Nintendo Switch ROM directories, following the
+// yuzu/ryujinx-style "merged"/"base" split produced by NSP-merging tools.
+type SwitchPlatform struct{}
+
+func init() {
+	register(SwitchPlatform{})
+}
+
+func (SwitchPlatform) Name() string { return "switch" }
+
+func (SwitchPlatform) InvalidDirNames() []string { return []string{"rw", "titles"} }
+
+func (SwitchPlatform) ValidSubdirNames() []string { return []string{"merged", "base"} }
+
+func (p SwitchPlatform) DetectROMs(ctx context.Context, rootDir string) ([]Rom, error) {
+	return detectBySubdir(ctx, rootDir, p.InvalidDirNames(), p.ValidSubdirNames())
+}
@@ -0,0 +1,25 @@
+package platform
+
+import "context"
+
+// ThreeDSPlatform detects 3DS ROM directories. 3DS titles are commonly kept
+// as separate CIAs for the base game, an update, and DLC; romfu unions
+// whichever of those are present into one mount, so a title only needs to
+// keep whatever layers it actually has.
+type ThreeDSPlatform struct{}
+
+func init() {
+	register(ThreeDSPlatform{})
+}
+
+func (ThreeDSPlatform) Name() string { return "3ds" }
+
+func (ThreeDSPlatform) InvalidDirNames() []string { return []string{"rw"} }
+
+// ValidSubdirNames is in priority order: where dlc, update, and base all
+// contain the same file, dlc wins, then update, then base.
+func (ThreeDSPlatform) ValidSubdirNames() []string { return []string{"dlc", "update", "base"} }
+
+func (p ThreeDSPlatform) DetectROMs(ctx context.Context, rootDir string) ([]Rom, error) {
+	return detectBySubdir(ctx, rootDir, p.InvalidDirNames(), p.ValidSubdirNames())
+}
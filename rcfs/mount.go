@@ -0,0 +1,32 @@
+package rcfs
+
+import (
+	"fmt"
+
+	_ "github.com/rclone/rclone/cmd/mount"
+	"github.com/rclone/rclone/cmd/mountlib"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/vfs/vfscommon"
+)
+
+// Mount FUSE-mounts f at destPath and blocks until it's unmounted. allowWrite
+// controls whether the mount is exposed read-write or read-only; romfu's own
+// "rw" upstream is what actually persists writes, so this just needs to let
+// them through.
+func Mount(f fs.Fs, destPath string, allowWrite bool) error {
+	vfsOpt := vfscommon.Opt
+	vfsOpt.ReadOnly = !allowWrite
+
+	_, mountFn := mountlib.ResolveMountMethod("")
+	if mountFn == nil {
+		return fmt.Errorf("no FUSE mount backend available")
+	}
+
+	mountPoint := mountlib.NewMountPoint(mountFn, destPath, f, &mountlib.Opt, &vfsOpt)
+
+	if _, err := mountPoint.Mount(); err != nil {
+		return err
+	}
+
+	return mountPoint.Wait()
+}
@@ -0,0 +1,45 @@
+// Package rcfs builds and serves romfu's composed filesystem directly
+// through the rclone Go library, rather than shelling out to the rclone
+// binary and configuring it through RCLONE_CONFIG_* environment variables.
+package rcfs
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config"
+
+	// Backends registered so fs.NewFs can resolve them by TYPE.
+	_ "github.com/rclone/rclone/backend/chunker"
+	_ "github.com/rclone/rclone/backend/compress"
+	_ "github.com/rclone/rclone/backend/crypt"
+	_ "github.com/rclone/rclone/backend/hasher"
+	_ "github.com/rclone/rclone/backend/local"
+	_ "github.com/rclone/rclone/backend/union"
+
+	"github.com/thatpix3l/romfu/remote"
+)
+
+// Build registers every spec as an in-memory rclone config section (the
+// in-process equivalent of the RCLONE_CONFIG_* env vars romfu used to set)
+// and returns the fs.Fs for topRemote, the remote sitting at the top of the
+// chain.
+func Build(ctx context.Context, specs []remote.Spec, topRemote string) (fs.Fs, error) {
+	for _, spec := range specs {
+		for key, value := range spec.Options {
+			// Config keys are read back lowercase (fs.NewFs -> m.Get("type")
+			// etc.), unlike the RCLONE_CONFIG_<NAME>_<KEY> env vars this
+			// replaced, which were upper-cased; callers must pass lowercase
+			// keys in spec.Options.
+			config.Data().SetValue(spec.Name, key, value)
+		}
+	}
+
+	f, err := fs.NewFs(ctx, topRemote+":")
+	if err != nil {
+		return nil, fmt.Errorf("building %s: %w", topRemote, err)
+	}
+
+	return f, nil
+}
@@ -0,0 +1,403 @@
+package rcfs
+
+import (
+	cryptorand "crypto/rand"
+	"crypto/ed25519"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/net/webdav"
+	ftpserver "goftp.io/server/v2"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/vfs"
+	"github.com/rclone/rclone/vfs/vfscommon"
+)
+
+// vfsHTTPFileSystem adapts a VFS to http.FileSystem. vfs.Handle already
+// implements http.File (Read/Seek/Close/Readdir/Stat), so there's nothing to
+// wrap beyond Open itself.
+type vfsHTTPFileSystem struct{ vfs *vfs.VFS }
+
+func (v vfsHTTPFileSystem) Open(name string) (http.File, error) {
+	return v.vfs.Open(name)
+}
+
+// vfsWebDAVFileSystem adapts a VFS to webdav.FileSystem.
+type vfsWebDAVFileSystem struct{ vfs *vfs.VFS }
+
+func (v vfsWebDAVFileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	return v.vfs.Mkdir(name, perm)
+}
+
+func (v vfsWebDAVFileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	return v.vfs.OpenFile(name, flag, perm)
+}
+
+func (v vfsWebDAVFileSystem) RemoveAll(ctx context.Context, name string) error {
+	node, err := v.vfs.Stat(name)
+	if err != nil {
+		return err
+	}
+	return node.RemoveAll()
+}
+
+func (v vfsWebDAVFileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	return v.vfs.Rename(oldName, newName)
+}
+
+func (v vfsWebDAVFileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	return v.vfs.Stat(name)
+}
+
+// vfsFTPDriver adapts a VFS to goftp.io/server/v2's Driver interface.
+type vfsFTPDriver struct{ vfs *vfs.VFS }
+
+func (v vfsFTPDriver) Stat(_ *ftpserver.Context, path string) (os.FileInfo, error) {
+	return v.vfs.Stat(path)
+}
+
+func (v vfsFTPDriver) ListDir(_ *ftpserver.Context, path string, callback func(os.FileInfo) error) error {
+	fis, err := v.vfs.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	for _, fi := range fis {
+		if err := callback(fi); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v vfsFTPDriver) DeleteDir(_ *ftpserver.Context, path string) error {
+	node, err := v.vfs.Stat(path)
+	if err != nil {
+		return err
+	}
+	return node.RemoveAll()
+}
+
+func (v vfsFTPDriver) DeleteFile(_ *ftpserver.Context, path string) error {
+	return v.vfs.Remove(path)
+}
+
+func (v vfsFTPDriver) Rename(_ *ftpserver.Context, oldName, newName string) error {
+	return v.vfs.Rename(oldName, newName)
+}
+
+func (v vfsFTPDriver) MakeDir(_ *ftpserver.Context, path string) error {
+	return v.vfs.Mkdir(path, 0777)
+}
+
+func (v vfsFTPDriver) GetFile(_ *ftpserver.Context, path string, offset int64) (int64, io.ReadCloser, error) {
+	file, err := v.vfs.Open(path)
+	if err != nil {
+		return 0, nil, err
+	}
+	if offset > 0 {
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			_ = file.Close()
+			return 0, nil, err
+		}
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return 0, nil, err
+	}
+	return fi.Size() - offset, file, nil
+}
+
+func (v vfsFTPDriver) PutFile(_ *ftpserver.Context, path string, data io.Reader, offset int64) (int64, error) {
+	flags := os.O_WRONLY | os.O_CREATE
+	if offset <= 0 {
+		flags |= os.O_TRUNC
+	}
+	file, err := v.vfs.OpenFile(path, flags, 0777)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	if offset > 0 {
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			return 0, err
+		}
+	}
+	return io.Copy(file, data)
+}
+
+// allowAllFTPAuth accepts any username/password, matching the lack of
+// authentication on romfu's http/webdav serving.
+type allowAllFTPAuth struct{}
+
+func (allowAllFTPAuth) CheckPasswd(*ftpserver.Context, string, string) (bool, error) {
+	return true, nil
+}
+
+// vfsSFTPHandler adapts a VFS to github.com/pkg/sftp's request-server
+// Handlers, the same pattern rclone's own (unexported) SFTP server uses.
+type vfsSFTPHandler struct{ vfs *vfs.VFS }
+
+func newSFTPHandlers(v *vfs.VFS) sftp.Handlers {
+	h := vfsSFTPHandler{vfs: v}
+	return sftp.Handlers{FileGet: h, FilePut: h, FileCmd: h, FileList: h}
+}
+
+func (v vfsSFTPHandler) Fileread(r *sftp.Request) (io.ReaderAt, error) {
+	return v.vfs.OpenFile(r.Filepath, os.O_RDONLY, 0777)
+}
+
+func (v vfsSFTPHandler) Filewrite(r *sftp.Request) (io.WriterAt, error) {
+	p := r.Pflags()
+	flags := os.O_WRONLY
+	if p.Append {
+		flags |= os.O_APPEND
+	}
+	if p.Creat {
+		flags |= os.O_CREATE
+	}
+	if p.Trunc {
+		flags |= os.O_TRUNC
+	}
+	if p.Excl {
+		flags |= os.O_EXCL
+	}
+	return v.vfs.OpenFile(r.Filepath, flags, 0777)
+}
+
+func (v vfsSFTPHandler) Filecmd(r *sftp.Request) error {
+	switch r.Method {
+	case "Setstat":
+		attr := r.Attributes()
+		flags := r.AttrFlags()
+		if flags.Size {
+			node, err := v.vfs.Stat(r.Filepath)
+			if err != nil {
+				return err
+			}
+			if err := node.Truncate(int64(attr.Size)); err != nil {
+				return err
+			}
+		}
+		if flags.Acmodtime {
+			modTime := time.Unix(int64(attr.Mtime), 0)
+			if err := v.vfs.Chtimes(r.Filepath, modTime, modTime); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "Rename":
+		return v.vfs.Rename(r.Filepath, r.Target)
+	case "Rmdir", "Remove":
+		return v.vfs.Remove(r.Filepath)
+	case "Mkdir":
+		return v.vfs.Mkdir(r.Filepath, 0777)
+	default:
+		return sftp.ErrSSHFxOpUnsupported
+	}
+}
+
+type sftpListerAt []os.FileInfo
+
+func (l sftpListerAt) ListAt(ls []os.FileInfo, offset int64) (int, error) {
+	if offset >= int64(len(l)) {
+		return 0, io.EOF
+	}
+	n := copy(ls, l[offset:])
+	if n < len(ls) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (v vfsSFTPHandler) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	switch r.Method {
+	case "List":
+		node, err := v.vfs.Stat(r.Filepath)
+		if err != nil {
+			return nil, err
+		}
+		if !node.IsDir() {
+			return nil, syscall.ENOTDIR
+		}
+		fis, err := v.vfs.ReadDir(r.Filepath)
+		if err != nil {
+			return nil, err
+		}
+		return sftpListerAt(fis), nil
+	case "Stat":
+		node, err := v.vfs.Stat(r.Filepath)
+		if err != nil {
+			return nil, err
+		}
+		return sftpListerAt([]os.FileInfo{node}), nil
+	default:
+		return nil, sftp.ErrSSHFxOpUnsupported
+	}
+}
+
+// newEphemeralHostKey generates a fresh ed25519 host key for the lifetime of
+// one SFTP listen, since romfu has nowhere persistent to keep one and
+// nothing in its CLI surface to configure one.
+func newEphemeralHostKey() (ssh.Signer, error) {
+	_, priv, err := ed25519.GenerateKey(cryptorand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return ssh.NewSignerFromKey(priv)
+}
+
+// serveSFTP listens on addr and serves VFS over SFTP until ctx is cancelled.
+// Any username/password is accepted, matching the lack of authentication on
+// romfu's other serve protocols.
+func serveSFTP(ctx context.Context, VFS *vfs.VFS, addr string) error {
+	hostKey, err := newEphemeralHostKey()
+	if err != nil {
+		return fmt.Errorf("generating SFTP host key: %w", err)
+	}
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			return nil, nil
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	for {
+		nConn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go serveSFTPConn(nConn, config, VFS)
+	}
+}
+
+func serveSFTPConn(nConn net.Conn, config *ssh.ServerConfig, VFS *vfs.VFS) {
+	defer nConn.Close()
+
+	sconn, chans, reqs, err := ssh.NewServerConn(nConn, config)
+	if err != nil {
+		fs.Errorf(nil, "SFTP handshake failed: %v", err)
+		return
+	}
+	defer sconn.Close()
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			for req := range requests {
+				req.Reply(req.Type == "subsystem", nil)
+			}
+		}()
+
+		server := sftp.NewRequestServer(channel, newSFTPHandlers(VFS))
+		server.Serve()
+		server.Close()
+	}
+}
+
+// Serve exposes f over proto at addr and blocks until ctx is cancelled.
+// allowWrite controls whether the VFS is writable; romfu's own "rw" upstream
+// is what actually persists writes, so this just needs to let them through.
+//
+// rclone's own cmd/serve/ftp and cmd/serve/sftp packages don't expose an
+// embeddable server constructor - only a cobra.Command whose Run always
+// ends in os.Exit - so those can't be reused from an in-process library like
+// this one. Instead, all four protocols are implemented here directly
+// against the VFS: http and webdav via Go's stdlib/x/net packages, ftp via
+// goftp.io/server (the library rclone's own FTP server is itself built on),
+// and sftp via github.com/pkg/sftp's request-server handlers (the same
+// approach rclone's own SFTP server takes internally).
+func Serve(ctx context.Context, f fs.Fs, proto string, addr string, allowWrite bool) error {
+	vfsOpt := vfscommon.Opt
+	vfsOpt.ReadOnly = !allowWrite
+	VFS := vfs.New(ctx, f, &vfsOpt)
+	defer VFS.Shutdown()
+
+	switch proto {
+	case "http":
+		return serveHTTP(ctx, addr, http.FileServer(vfsHTTPFileSystem{vfs: VFS}))
+	case "webdav":
+		handler := &webdav.Handler{FileSystem: vfsWebDAVFileSystem{vfs: VFS}, LockSystem: webdav.NewMemLS()}
+		return serveHTTP(ctx, addr, handler)
+	case "ftp":
+		host, portStr, err := net.SplitHostPort(addr)
+		if err != nil {
+			return fmt.Errorf("parsing FTP listen address %q: %w", addr, err)
+		}
+		var port int
+		if _, err := fmt.Sscanf(portStr, "%d", &port); err != nil {
+			return fmt.Errorf("parsing FTP listen port %q: %w", portStr, err)
+		}
+		srv, err := ftpserver.NewServer(&ftpserver.Options{
+			Name:     "romfu",
+			Driver:   vfsFTPDriver{vfs: VFS},
+			Auth:     allowAllFTPAuth{},
+			Hostname: host,
+			Port:     port,
+		})
+		if err != nil {
+			return err
+		}
+		go func() {
+			<-ctx.Done()
+			srv.Shutdown()
+		}()
+		if err := srv.ListenAndServe(); err != nil && err != ftpserver.ErrServerClosed {
+			return err
+		}
+		return nil
+	case "sftp":
+		return serveSFTP(ctx, VFS, addr)
+	default:
+		return fmt.Errorf("serve protocol %q is not supported (must be one of http, webdav, ftp, sftp)", proto)
+	}
+}
+
+// serveHTTP runs an *http.Server with handler until ctx is cancelled.
+func serveHTTP(ctx context.Context, addr string, handler http.Handler) error {
+	server := &http.Server{Addr: addr, Handler: handler}
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- server.ListenAndServe() }()
+
+	select {
+	case <-ctx.Done():
+		return server.Close()
+	case err := <-errCh:
+		if err != nil && err != http.ErrServerClosed {
+			return err
+		}
+		return nil
+	}
+}
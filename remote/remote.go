@@ -0,0 +1,88 @@
+// Package remote builds the chain of rclone virtual-backend remotes (crypt,
+// chunker, compress, hasher) that can be layered over romfu's union remote,
+// so the final mount target is whichever backend sits at the top of the
+// chain.
+package remote
+
+import (
+	"fmt"
+
+	"github.com/rclone/rclone/fs/config/obscure"
+)
+
+// Spec is a single rclone remote, registered by rcfs.Build as an in-memory
+// rclone config section named Name.
+type Spec struct {
+	Name    string
+	Options map[string]string
+}
+
+// Options selects which virtual backends to layer over the base remote, and
+// how to configure each one. Backends are applied in a fixed order —
+// chunker, then compress, then crypt, then hasher — each wrapping whichever
+// remote came before it, so data written through the mount is encrypted
+// first, then compressed, then chunked, and the hasher's cache sits closest
+// to the mount.
+type Options struct {
+	ChunkSize string // e.g. "4G"; empty disables chunker
+	Compress  bool
+	Encrypt   bool
+	Password  string // crypt password; required if Encrypt is set
+	HashCache bool
+}
+
+// Chain builds the ordered list of Specs to layer over base, returning the
+// full spec list (including base) and the name of the remote that should
+// actually be mounted.
+func Chain(base Spec, opts Options) ([]Spec, string, error) {
+	specs := []Spec{base}
+	top := base.Name
+
+	if opts.ChunkSize != "" {
+		top = appendSpec(&specs, "CHUNKER", map[string]string{
+			"type":       "chunker",
+			"remote":     top + ":",
+			"chunk_size": opts.ChunkSize,
+		})
+	}
+
+	if opts.Compress {
+		top = appendSpec(&specs, "COMPRESS", map[string]string{
+			"type":   "compress",
+			"remote": top + ":",
+		})
+	}
+
+	if opts.Encrypt {
+		// rclone's crypt backend expects an obscured password in config and
+		// un-obscures it itself at NewFs time; a plaintext value would fail
+		// to initialize the remote.
+		obscured, err := obscure.Obscure(opts.Password)
+		if err != nil {
+			return nil, "", fmt.Errorf("obscuring crypt password: %w", err)
+		}
+		top = appendSpec(&specs, "CRYPT", map[string]string{
+			"type":     "crypt",
+			"remote":   top + ":",
+			"password": obscured,
+		})
+	}
+
+	if opts.HashCache {
+		top = appendSpec(&specs, "HASHER", map[string]string{
+			"type":   "hasher",
+			"remote": top + ":",
+		})
+	}
+
+	return specs, top, nil
+}
+
+// appendSpec adds a new remote named "ROMFU<suffix>" wrapping whatever was
+// already at the top of the chain, and returns its name.
+func appendSpec(specs *[]Spec, suffix string, options map[string]string) string {
+	name := "ROMFU" + suffix
+	*specs = append(*specs, Spec{Name: name, Options: options})
+	return name
+}
+
@@ -0,0 +1,81 @@
+package remote
+
+import "testing"
+
+func TestChain(t *testing.T) {
+	base := Spec{Name: "ROMFUUNION", Options: map[string]string{"type": "union"}}
+
+	tests := []struct {
+		name      string
+		opts      Options
+		wantNames []string // spec names, in order, including base
+		wantTop   string
+	}{
+		{
+			name:      "no backends",
+			opts:      Options{},
+			wantNames: []string{"ROMFUUNION"},
+			wantTop:   "ROMFUUNION",
+		},
+		{
+			name:      "chunker only",
+			opts:      Options{ChunkSize: "4G"},
+			wantNames: []string{"ROMFUUNION", "ROMFUCHUNKER"},
+			wantTop:   "ROMFUCHUNKER",
+		},
+		{
+			name:      "full chain in fixed order",
+			opts:      Options{ChunkSize: "4G", Compress: true, Encrypt: true, Password: "hunter2", HashCache: true},
+			wantNames: []string{"ROMFUUNION", "ROMFUCHUNKER", "ROMFUCOMPRESS", "ROMFUCRYPT", "ROMFUHASHER"},
+			wantTop:   "ROMFUHASHER",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			specs, top, err := Chain(base, tt.opts)
+			if err != nil {
+				t.Fatalf("Chain: %v", err)
+			}
+
+			if top != tt.wantTop {
+				t.Errorf("top = %q, want %q", top, tt.wantTop)
+			}
+
+			if len(specs) != len(tt.wantNames) {
+				t.Fatalf("got %d specs, want %d: %+v", len(specs), len(tt.wantNames), specs)
+			}
+			for i, name := range tt.wantNames {
+				if specs[i].Name != name {
+					t.Errorf("specs[%d].Name = %q, want %q", i, specs[i].Name, name)
+				}
+			}
+
+			// Each non-base spec must point "remote" at the previous top.
+			for i := 1; i < len(specs); i++ {
+				want := specs[i-1].Name + ":"
+				if got := specs[i].Options["remote"]; got != want {
+					t.Errorf("specs[%d].Options[remote] = %q, want %q", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestChainCryptObscuresPassword(t *testing.T) {
+	base := Spec{Name: "ROMFUUNION", Options: map[string]string{"type": "union"}}
+
+	specs, top, err := Chain(base, Options{Encrypt: true, Password: "hunter2"})
+	if err != nil {
+		t.Fatalf("Chain: %v", err)
+	}
+
+	if top != "ROMFUCRYPT" {
+		t.Fatalf("top = %q, want ROMFUCRYPT", top)
+	}
+
+	got := specs[len(specs)-1].Options["password"]
+	if got == "" || got == "hunter2" {
+		t.Fatalf("password = %q, want an obscured (non-empty, non-plaintext) value", got)
+	}
+}
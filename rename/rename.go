@@ -0,0 +1,169 @@
+// Package rename walks a ROM tree, matches files against a Dat by hash, and
+// renames them to their canonical name. It's kept separate from main so
+// future scrape/dedup commands can reuse the same hashing and DAT-matching
+// pipeline.
+package rename
+
+import (
+	"archive/zip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+
+	"github.com/thatpix3l/romfu/dat"
+	"github.com/thatpix3l/romfu/hashutil"
+)
+
+// Options configures a Run.
+type Options struct {
+	Dat          *dat.Dat
+	RootDir      string
+	DryRun       bool // Print "old -> new" instead of touching any files
+	KeepOriginal bool // Hardlink to the canonical name instead of renaming
+	Workers      int  // Number of parallel hashing workers
+}
+
+// Result describes what Run did, or would do, to a single file.
+type Result struct {
+	OldPath string
+	NewPath string
+	Matched bool
+}
+
+// Run walks opts.RootDir, hashes every ROM file it finds, and renames (or
+// hardlinks, or dry-run-prints) each one that matches an entry in opts.Dat.
+func Run(opts Options) ([]Result, error) {
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+
+	paths := make(chan string, opts.Workers)
+	results := make(chan Result, opts.Workers)
+
+	var walkErr error
+	go func() {
+		defer close(paths)
+		walkErr = filepath.Walk(opts.RootDir, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			if strings.HasPrefix(filepath.Base(p), ".") {
+				return nil
+			}
+			paths <- p
+			return nil
+		})
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range paths {
+				result, err := processFile(opts, p)
+				if err != nil {
+					color.Red("%s: %v", p, err)
+					continue
+				}
+				if result != nil {
+					results <- *result
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	all := []Result{}
+	for result := range results {
+		if opts.DryRun {
+			fmt.Printf("%s -> %s\n", color.YellowString(result.OldPath), color.GreenString(result.NewPath))
+		}
+		all = append(all, result)
+	}
+
+	return all, walkErr
+}
+
+// processFile hashes a single ROM file (hashing the inner file rather than
+// the archive itself, if it's a zip) and looks it up in the Dat.
+func processFile(opts Options, path string) (*Result, error) {
+	hashes, name, err := hashROM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates, ok := opts.Dat.Candidates(hashes.CRC32, hashes.SHA1)
+	if !ok {
+		return nil, nil
+	}
+
+	best := dat.Resolve(candidates, name)
+	newPath := filepath.Join(filepath.Dir(path), best.Name+filepath.Ext(path))
+
+	result := &Result{OldPath: path, NewPath: newPath, Matched: true}
+
+	if opts.DryRun || newPath == path {
+		return result, nil
+	}
+
+	if _, err := os.Stat(newPath); err == nil {
+		return nil, fmt.Errorf("%s: canonical name %s already exists, skipping to avoid overwriting it", path, newPath)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if opts.KeepOriginal {
+		err = os.Link(path, newPath)
+	} else {
+		err = os.Rename(path, newPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// hashROM hashes path, hashing the first file inside a zip archive rather
+// than the archive itself when path is a zipped ROM.
+func hashROM(path string) (hashutil.FileHashes, string, error) {
+	if strings.EqualFold(filepath.Ext(path), ".zip") {
+		r, err := zip.OpenReader(path)
+		if err != nil {
+			return hashutil.FileHashes{}, "", err
+		}
+		defer r.Close()
+
+		for _, f := range r.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return hashutil.FileHashes{}, "", err
+			}
+			defer rc.Close()
+
+			hashes, err := hashutil.HashesForReader(rc)
+			return hashes, strings.TrimSuffix(f.Name, filepath.Ext(f.Name)), err
+		}
+
+		return hashutil.FileHashes{}, "", fmt.Errorf("%s: empty zip", path)
+	}
+
+	hashes, err := hashutil.HashesForFile(path)
+	base := filepath.Base(path)
+	return hashes, strings.TrimSuffix(base, filepath.Ext(base)), err
+}